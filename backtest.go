@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/adshao/go-binance/v2"
+)
+
+// StrategyParams 是回测中可调的 MA60 检测参数，便于在部署前调参
+type StrategyParams struct {
+	MAWindow       int // 均线窗口，默认 60
+	ConfirmCandles int // 连续多少根K线收在均线同侧才确认突破/跌破，默认 1
+}
+
+// DefaultStrategyParams 返回与线上行为一致的默认参数
+func DefaultStrategyParams() StrategyParams {
+	return StrategyParams{MAWindow: 60, ConfirmCandles: 1}
+}
+
+// BacktestSignal 记录一次历史信号及其触发位置，便于计算后续收益
+type BacktestSignal struct {
+	Index int
+	Type  SignalType
+	Price float64
+}
+
+// DetectSignals 是从 trackAndAnalyze 中抽取出的纯函数版本 MA60 检测逻辑，
+// 不依赖任何全局状态，只根据K线和参数计算出全部历史信号，供回测复用。
+func DetectSignals(klines []*binance.Kline, params StrategyParams) []BacktestSignal {
+	window := params.MAWindow
+	confirm := params.ConfirmCandles
+	if confirm < 1 {
+		confirm = 1
+	}
+	if len(klines) < window+confirm {
+		return nil
+	}
+
+	prices := closePrices(klines)
+	var signals []BacktestSignal
+
+	for i := window + confirm - 1; i < len(prices); i++ {
+		ma := sma(prices[i-window-confirm+1:i-confirm+1], window)
+		allAbove, allBelow := true, true
+		for c := 0; c < confirm; c++ {
+			if prices[i-c] <= ma {
+				allAbove = false
+			}
+			if prices[i-c] >= ma {
+				allBelow = false
+			}
+		}
+		prevClose := prices[i-confirm]
+
+		if allAbove && prevClose <= ma {
+			signals = append(signals, BacktestSignal{Index: i, Type: SignalBreakout, Price: prices[i]})
+		}
+		if allBelow && prevClose >= ma {
+			signals = append(signals, BacktestSignal{Index: i, Type: SignalBreakdown, Price: prices[i]})
+		}
+	}
+	return signals
+}
+
+// SymbolPerformance 汇总单个交易对在回测区间内的信号表现
+type SymbolPerformance struct {
+	Symbol      string
+	SignalCount int
+	WinRate     float64
+	AvgGain7d   float64
+	AvgGain30d  float64
+	AvgGain90d  float64
+	MaxDrawdown float64
+}
+
+// forwardReturn 计算从 index 开始往后 horizon 根K线的涨跌幅，数据不足时返回 false
+func forwardReturn(prices []float64, index, horizon int) (float64, bool) {
+	target := index + horizon
+	if target >= len(prices) {
+		return 0, false
+	}
+	return (prices[target] - prices[index]) / prices[index] * 100, true
+}
+
+// evaluateSymbolPerformance 根据一个交易对的历史信号计算胜率、各周期平均收益和最大回撤
+func evaluateSymbolPerformance(symbol string, klines []*binance.Kline, signals []BacktestSignal) SymbolPerformance {
+	prices := closePrices(klines)
+	perf := SymbolPerformance{Symbol: symbol, SignalCount: len(signals)}
+	if len(signals) == 0 {
+		return perf
+	}
+
+	var wins int
+	var sum7, sum30, sum90 float64
+	var n7, n30, n90 int
+	peak := prices[signals[0].Index]
+	var maxDrawdown float64
+
+	for _, sig := range signals {
+		direction := 1.0
+		if sig.Type == SignalBreakdown {
+			direction = -1.0
+		}
+
+		if ret, ok := forwardReturn(prices, sig.Index, 7); ok {
+			sum7 += ret * direction
+			n7++
+		}
+		if ret, ok := forwardReturn(prices, sig.Index, 30); ok {
+			sum30 += ret * direction
+			n30++
+			if ret*direction > 0 {
+				wins++
+			}
+		}
+		if ret, ok := forwardReturn(prices, sig.Index, 90); ok {
+			sum90 += ret * direction
+			n90++
+		}
+
+		if prices[sig.Index] > peak {
+			peak = prices[sig.Index]
+		}
+		drawdown := (peak - prices[sig.Index]) / peak * 100
+		if drawdown > maxDrawdown {
+			maxDrawdown = drawdown
+		}
+	}
+
+	if n7 > 0 {
+		perf.AvgGain7d = sum7 / float64(n7)
+	}
+	if n30 > 0 {
+		perf.AvgGain30d = sum30 / float64(n30)
+		perf.WinRate = float64(wins) / float64(n30) * 100
+	}
+	if n90 > 0 {
+		perf.AvgGain90d = sum90 / float64(n90)
+	}
+	perf.MaxDrawdown = maxDrawdown
+	return perf
+}
+
+// parseBacktestSymbols 把逗号分隔的交易对列表解析为大写集合，空字符串表示不限制
+func parseBacktestSymbols(raw string) map[string]bool {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, s := range strings.Split(raw, ",") {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s != "" {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+// runBacktest 下载历史K线，用 DetectSignals 重放 MA60 检测逻辑，并把每个交易对的表现写入 CSV
+func runBacktest(months int, symbolFilter string) error {
+	universe := buildUniverse()
+	allowed := parseBacktestSymbols(symbolFilter)
+
+	limit := months*31 + DefaultStrategyParams().MAWindow
+	if limit > 1000 {
+		limit = 1000 // Binance K线接口单次请求上限
+	}
+	params := DefaultStrategyParams()
+
+	outFile, err := os.Create("backtest_report.csv")
+	if err != nil {
+		return fmt.Errorf("创建回测报告文件失败: %w", err)
+	}
+	defer outFile.Close()
+
+	writer := csv.NewWriter(outFile)
+	defer writer.Flush()
+	if err := writer.Write([]string{"symbol", "signals", "win_rate_30d_pct", "avg_gain_7d_pct", "avg_gain_30d_pct", "avg_gain_90d_pct", "max_drawdown_pct"}); err != nil {
+		return fmt.Errorf("写入CSV表头失败: %w", err)
+	}
+
+	for _, ms := range universe {
+		if allowed != nil && !allowed[ms.Symbol] {
+			continue
+		}
+
+		klines, err := fetchKlines(ms, "1d", limit)
+		if err != nil || len(klines) < params.MAWindow+1 {
+			log.Printf("跳过 %s: 获取历史K线失败或数据不足 (%v)", ms.Symbol, err)
+			continue
+		}
+
+		signals := DetectSignals(klines, params)
+		perf := evaluateSymbolPerformance(ms.Symbol, klines, signals)
+		log.Printf("回测完成: %s 信号数=%d 30日胜率=%.2f%%", perf.Symbol, perf.SignalCount, perf.WinRate)
+
+		row := []string{
+			perf.Symbol,
+			strconv.Itoa(perf.SignalCount),
+			fmt.Sprintf("%.2f", perf.WinRate),
+			fmt.Sprintf("%.2f", perf.AvgGain7d),
+			fmt.Sprintf("%.2f", perf.AvgGain30d),
+			fmt.Sprintf("%.2f", perf.AvgGain90d),
+			fmt.Sprintf("%.2f", perf.MaxDrawdown),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("写入 %s 的回测结果失败: %w", ms.Symbol, err)
+		}
+	}
+
+	log.Println("回测报告已写入 backtest_report.csv")
+	return nil
+}
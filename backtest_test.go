@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestDetectSignalsInsufficientData(t *testing.T) {
+	klines := pricesToKlines([]float64{10, 10, 10})
+	params := StrategyParams{MAWindow: 3, ConfirmCandles: 1}
+	if got := DetectSignals(klines, params); got != nil {
+		t.Errorf("DetectSignals(数据不足) = %v, want nil", got)
+	}
+}
+
+func TestDetectSignalsBreakout(t *testing.T) {
+	klines := pricesToKlines([]float64{10, 10, 10, 10, 20})
+	params := StrategyParams{MAWindow: 3, ConfirmCandles: 1}
+
+	signals := DetectSignals(klines, params)
+	if len(signals) != 1 {
+		t.Fatalf("DetectSignals() 返回 %d 个信号, want 1 (%+v)", len(signals), signals)
+	}
+	got := signals[0]
+	if got.Index != 4 || got.Type != SignalBreakout || got.Price != 20 {
+		t.Errorf("DetectSignals() = %+v, want {Index:4 Type:breakout Price:20}", got)
+	}
+}
+
+func TestDetectSignalsBreakdown(t *testing.T) {
+	klines := pricesToKlines([]float64{10, 10, 10, 10, 0})
+	params := StrategyParams{MAWindow: 3, ConfirmCandles: 1}
+
+	signals := DetectSignals(klines, params)
+	if len(signals) != 1 {
+		t.Fatalf("DetectSignals() 返回 %d 个信号, want 1 (%+v)", len(signals), signals)
+	}
+	got := signals[0]
+	if got.Index != 4 || got.Type != SignalBreakdown || got.Price != 0 {
+		t.Errorf("DetectSignals() = %+v, want {Index:4 Type:breakdown Price:0}", got)
+	}
+}
+
+func TestDetectSignalsFlatNoSignal(t *testing.T) {
+	klines := pricesToKlines([]float64{10, 10, 10, 10, 10, 10})
+	params := StrategyParams{MAWindow: 3, ConfirmCandles: 1}
+	if got := DetectSignals(klines, params); len(got) != 0 {
+		t.Errorf("DetectSignals(无波动) = %+v, want 无信号", got)
+	}
+}
@@ -1,89 +1,114 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/futures"
 	"github.com/joho/godotenv"
 )
 
-// TrackedAsset 代表一个我们正在监控的币种状态
+// MarketMode 决定本次运行监控哪些市场
+type MarketMode string
+
+const (
+	MarketModeSpot    MarketMode = "spot"
+	MarketModeFutures MarketMode = "futures"
+	MarketModeBoth    MarketMode = "both"
+)
+
+// ContractInfo 记录合约相关的元数据，现货交易对该字段为空值
+type ContractInfo struct {
+	TickSize      float64 `json:"tickSize,omitempty"`      // 最小价格变动单位
+	QtyStep       float64 `json:"qtyStep,omitempty"`       // 最小下单数量步长
+	ContractValue float64 `json:"contractValue,omitempty"` // 合约面值（张）
+	DeliveryDate  string  `json:"deliveryDate,omitempty"`  // 交割日期，永续合约为空
+}
+
+// TrackedAsset 代表某个交易对在某个策略下的监控状态
 type TrackedAsset struct {
-	Symbol     string  `json:"symbol"`
-	Status     string  `json:"status"` // "breakout" 或 "breakdown"
-	EventPrice float64 `json:"eventPrice"`
-	EventDate  string  `json:"eventDate"`
+	Symbol     string       `json:"symbol"`
+	StrategyID string       `json:"strategyId"` // 来自 StrategyConfig 的 name，用于在同一交易对上区分多个并发策略
+	Status     string       `json:"status"`     // "breakout"/"breakdown"/"buy"/"sell"
+	EventPrice float64      `json:"eventPrice"`
+	EventDate  string       `json:"eventDate"`
+	Market     string       `json:"market"` // "spot" 或 "futures"
+	Contract   ContractInfo `json:"contract,omitempty"`
 }
 
 const STATE_FILE = "state.json"
 
 var (
 	DINGTALK_WEBHOOK_URL string
+	marketMode           MarketMode
 	binanceClient        *binance.Client
+	futuresClient        *futures.Client
+	stateStore           StateStore
 	trackedAssets        map[string]TrackedAsset // 用于存储状态的内存变量
 )
 
-// loadStateFromFile 从 JSON 文件加载状态到内存
-func loadStateFromFile() error {
-	trackedAssets = make(map[string]TrackedAsset)
-	data, err := os.ReadFile(STATE_FILE)
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Println("状态文件不存在，将创建一个新的。")
-			return nil // 文件不存在是正常情况，直接返回
-		}
-		return fmt.Errorf("读取状态文件失败: %w", err)
+// parseMarketMode 读取并校验 MARKET_MODE 环境变量，默认值为 spot
+func parseMarketMode(raw string) MarketMode {
+	switch MarketMode(strings.ToLower(strings.TrimSpace(raw))) {
+	case MarketModeFutures:
+		return MarketModeFutures
+	case MarketModeBoth:
+		return MarketModeBoth
+	default:
+		return MarketModeSpot
 	}
-	if len(data) == 0 {
-		return nil // 空文件
-	}
-	err = json.Unmarshal(data, &trackedAssets)
-	if err != nil {
-		return fmt.Errorf("解析状态文件JSON失败: %w", err)
-	}
-	log.Printf("成功从 %s 加载 %d 个币种的状态。", STATE_FILE, len(trackedAssets))
-	return nil
-}
-
-// saveStateToFile 将内存中的状态保存到 JSON 文件
-func saveStateToFile() error {
-	data, err := json.MarshalIndent(trackedAssets, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化状态到JSON失败: %w", err)
-	}
-	err = os.WriteFile(STATE_FILE, data, 0644)
-	if err != nil {
-		return fmt.Errorf("写入状态文件失败: %w", err)
-	}
-	log.Printf("成功将 %d 个币种的状态保存到 %s。", len(trackedAssets), STATE_FILE)
-	return nil
 }
 
 func main() {
+	backtestFlag := flag.Bool("backtest", false, "运行回测模式：下载历史K线并重放检测逻辑，生成 backtest_report.csv 后退出")
+	backtestMonths := flag.Int("backtest-months", 6, "回测回溯的月数")
+	backtestSymbols := flag.String("backtest-symbols", "", "仅回测指定交易对（逗号分隔），留空表示全部")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		log.Fatal("错误: 无法加载 .env 文件。")
 	}
 
 	apiKey := os.Getenv("BINANCE_API_KEY")
 	secretKey := os.Getenv("BINANCE_SECRET_KEY")
-	binanceClient = binance.NewClient(apiKey, secretKey)
+	marketMode = parseMarketMode(os.Getenv("MARKET_MODE"))
+	if marketMode == MarketModeSpot || marketMode == MarketModeBoth {
+		binanceClient = binance.NewClient(apiKey, secretKey)
+	}
+	if marketMode == MarketModeFutures || marketMode == MarketModeBoth {
+		futuresClient = futures.NewClient(apiKey, secretKey)
+	}
+
+	if *backtestFlag {
+		if err := runBacktest(*backtestMonths, *backtestSymbols); err != nil {
+			log.Fatalf("错误: 回测失败: %v", err)
+		}
+		return
+	}
+
 	DINGTALK_WEBHOOK_URL = os.Getenv("DINGTALK_WEBHOOK_URL")
+	initStopLossConfig()
+	stateStore = NewStateStore(os.Getenv("STATE_BACKEND"))
+	watchMode := parseWatchMode(os.Getenv("WATCH_MODE"))
+	log.Printf("监控模式: %s, 止损: %.2f%%, 止盈: %.2f%%, 监听方式: %s", marketMode, stopLossPct, takeProfitPct, watchMode)
 
 	log.Println("程序启动，执行首次即时检测...")
 	runCheck()
 
-	log.Println("首次检测完成。启动每日定时任务...")
-	go schedule()
+	if watchMode == WatchModeStream {
+		log.Println("首次检测完成。启动WebSocket实时K线监听...")
+		go runStreamMode()
+	} else {
+		log.Println("首次检测完成。启动每日定时任务...")
+		go schedule()
+	}
 
 	select {}
 }
@@ -107,159 +132,270 @@ func schedule() {
 func runCheck() {
 	log.Println("开始新一轮检测...")
 	// 1. 加载状态
-	if err := loadStateFromFile(); err != nil {
+	assets, err := stateStore.Load()
+	if err != nil {
 		log.Printf("严重错误: 加载状态失败: %v", err)
 		return
 	}
+	trackedAssets = assets
 
 	// 2. 分析和追踪
-	dailyBreakouts, dailyBreakdowns, trackedGains, trackedLosses := trackAndAnalyze()
+	dailyBreakouts, dailyBreakdowns, trackedGains, trackedLosses, stopEvents, signals := trackAndAnalyze()
 
 	// 3. 发送报告
-	sendFourPartDingTalkMessage(dailyBreakouts, dailyBreakdowns, trackedGains, trackedLosses)
+	report := Report{
+		Title: "MA60 均线监控",
+		Sections: []ReportSection{
+			{Title: "🚀 当日突破 (MA60)", Items: dailyBreakouts},
+			{Title: "🚨 当日跌破 (MA60)", Items: dailyBreakdowns},
+			{Title: "📈 已突破币种追踪", Items: trackedGains},
+			{Title: "📉 已跌破币种追踪", Items: trackedLosses},
+			{Title: "🛑 止损触发", Items: stopEvents},
+		},
+		Signals: signals,
+	}
+	dispatchNotifications(report)
 
 	// 4. 保存状态
-	if err := saveStateToFile(); err != nil {
+	if err := stateStore.Save(trackedAssets); err != nil {
 		log.Printf("严重错误: 保存状态失败: %v", err)
 	}
 	log.Println("本轮检测完成。")
 }
 
-// trackAndAnalyze 核心分析逻辑
-func trackAndAnalyze() (dailyBreakouts, dailyBreakdowns, trackedGains, trackedLosses []string) {
-	symbols, err := getAllUSDTSymbols()
-	if err != nil {
-		log.Printf("错误: 获取交易对列表失败: %v", err)
-		return
+// marketLabel 返回报告中用于区分现货/合约的简短前缀
+func marketLabel(market MarketMode) string {
+	if market == MarketModeFutures {
+		return "[合约]"
 	}
+	return "[现货]"
+}
 
-	for _, s := range symbols {
-		klines, err := binanceClient.NewKlinesService().Symbol(s).Interval("1d").Limit(61).Do(context.Background())
-		if err != nil || len(klines) < 61 {
-			continue
+// buildUniverse 汇总当前 MARKET_MODE 下所有需要监控的交易对
+func buildUniverse() []MonitoredSymbol {
+	var universe []MonitoredSymbol
+	if marketMode == MarketModeSpot || marketMode == MarketModeBoth {
+		spotSymbols, err := getAllUSDTSymbols()
+		if err != nil {
+			log.Printf("错误: 获取现货交易对列表失败: %v", err)
+		} else {
+			universe = append(universe, spotSymbols...)
 		}
-
-		var sum float64
-		for i := 0; i < 60; i++ {
-			p, _ := strconv.ParseFloat(klines[i].Close, 64)
-			sum += p
+	}
+	if marketMode == MarketModeFutures || marketMode == MarketModeBoth {
+		futuresSymbols, err := getAllFuturesUSDTSymbols()
+		if err != nil {
+			log.Printf("错误: 获取合约交易对列表失败: %v", err)
+		} else {
+			universe = append(universe, futuresSymbols...)
 		}
-		ma60 := sum / 60
+	}
+	return universe
+}
 
-		previousClose, _ := strconv.ParseFloat(klines[59].Close, 64)
-		latestClose, _ := strconv.ParseFloat(klines[60].Close, 64)
+// universeKey 组合市场和交易对代码，作为 universe 查找表的键；现货和合约可能共用同一个
+// 代码（如 BTCUSDT），只用 symbol 做键会让其中一个市场的记录覆盖另一个。
+func universeKey(market MarketMode, symbol string) string {
+	return string(market) + ":" + symbol
+}
 
-		isNewBreakout := latestClose > ma60 && previousClose <= ma60
-		isNewBreakdown := latestClose < ma60 && previousClose >= ma60
+// trackAndAnalyze 核心分析逻辑：并发运行所有已配置的策略，汇总信号并维护历史追踪状态
+func trackAndAnalyze() (dailyBreakouts, dailyBreakdowns, trackedGains, trackedLosses, stopEvents []string, signals []Signal) {
+	universe := buildUniverse()
 
-		// 情况一：当日新突破
-		if isNewBreakout {
-			report := fmt.Sprintf("%s (突破价: %f)", s, latestClose)
-			dailyBreakouts = append(dailyBreakouts, report)
-			trackedAssets[s] = TrackedAsset{
-				Symbol:     s,
-				Status:     "breakout",
-				EventPrice: latestClose,
-				EventDate:  time.Now().Format("2006-01-02"),
-			}
-			continue // 处理完当日事件后，跳过追踪逻辑
+	configs, err := LoadStrategyConfigs(STRATEGY_CONFIG_FILE)
+	if err != nil {
+		log.Printf("错误: 加载策略配置失败: %v", err)
+		return
+	}
+
+	bySymbol := make(map[string]MonitoredSymbol, len(universe))
+	for _, ms := range universe {
+		bySymbol[universeKey(ms.Market, ms.Symbol)] = ms
+	}
+
+	signals = runStrategiesConcurrently(configs, universe)
+	triggeredKeys := make(map[string]bool, len(signals))
+
+	for _, sig := range signals {
+		ms, ok := bySymbol[universeKey(MarketMode(sig.Market), sig.Symbol)]
+		if !ok {
+			continue
 		}
+		label := marketLabel(ms.Market)
+		key := assetKey(sig.Symbol, sig.StrategyID, sig.Market)
+		triggeredKeys[key] = true
 
-		// 情况二：当日新跌破
-		if isNewBreakdown {
-			report := fmt.Sprintf("%s (跌破价: %f)", s, latestClose)
+		report := fmt.Sprintf("%s %s [%s] %s", label, sig.Symbol, sig.StrategyID, sig.Message)
+		switch sig.Type {
+		case SignalBreakout, SignalBuy:
+			dailyBreakouts = append(dailyBreakouts, report)
+		case SignalBreakdown, SignalSell:
 			dailyBreakdowns = append(dailyBreakdowns, report)
-			trackedAssets[s] = TrackedAsset{
-				Symbol:     s,
-				Status:     "breakdown",
-				EventPrice: latestClose,
-				EventDate:  time.Now().Format("2006-01-02"),
-			}
-			continue // 处理完当日事件后，跳过追踪逻辑
+		}
+		if err := stateStore.RecordSignal(sig, string(ms.Market), time.Now().Format(time.RFC3339)); err != nil {
+			log.Printf("错误: 记录信号历史失败: %v", err)
 		}
 
-		// 情况三：追踪历史状态
-		if asset, ok := trackedAssets[s]; ok {
-			// 追踪已突破的币种
-			if asset.Status == "breakout" && latestClose > ma60 {
-				gain := (latestClose - asset.EventPrice) / asset.EventPrice * 100
-				report := fmt.Sprintf("%s (从 %f 至今涨幅: %.2f%%)", s, asset.EventPrice, gain)
-				trackedGains = append(trackedGains, report)
-			}
-			// 追踪已跌破的币种
-			if asset.Status == "breakdown" && latestClose < ma60 {
-				loss := (asset.EventPrice - latestClose) / asset.EventPrice * 100
-				report := fmt.Sprintf("%s (从 %f 至今跌幅: %.2f%%)", s, asset.EventPrice, loss)
-				trackedLosses = append(trackedLosses, report)
-			}
+		trackedAssets[key] = TrackedAsset{
+			Symbol:     sig.Symbol,
+			StrategyID: sig.StrategyID,
+			Status:     string(sig.Type),
+			EventPrice: sig.Price,
+			EventDate:  time.Now().Format("2006-01-02"),
+			Market:     string(ms.Market),
+			Contract:   ms.Contract,
 		}
 	}
-	return
-}
-
-// sendFourPartDingTalkMessage 发送包含四部分的钉钉消息
-func sendFourPartDingTalkMessage(dailyBreakouts, dailyBreakdowns, trackedGains, trackedLosses []string) {
-	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("### MA60 均线监控日报 (%s)\n\n", time.Now().Format("2006-01-02")))
 
-	formatSection := func(title string, items []string) {
-		builder.WriteString(fmt.Sprintf("**%s**\n\n", title))
-		if len(items) > 0 {
-			for _, item := range items {
-				builder.WriteString(fmt.Sprintf("- %s\n", item))
+	// 情况三：对本轮未触发新信号、但仍在追踪中的资产检查止损止盈，
+	// 突破/跌破类资产额外计算累计涨跌幅用于展示
+	for key, asset := range trackedAssets {
+		if triggeredKeys[key] {
+			continue
+		}
+		switch asset.Status {
+		case string(SignalBreakout), string(SignalBreakdown), string(SignalBuy), string(SignalSell):
+		default:
+			continue
+		}
+		ms, ok := bySymbol[universeKey(MarketMode(asset.Market), asset.Symbol)]
+		if !ok {
+			continue
+		}
+		klines, err := fetchKlines(ms, "1d", 1)
+		if err != nil || len(klines) == 0 {
+			continue
+		}
+		latestClose, _ := strconv.ParseFloat(klines[len(klines)-1].Close, 64)
+		label := marketLabel(ms.Market)
+
+		// 优先判断是否触发止损/止盈，触发后平仓并跳过常规的涨跌幅追踪
+		if closed, reason, pnlPercent := checkStopThreshold(asset, latestClose); closed {
+			report := fmt.Sprintf("%s %s [%s] %s触发 (从 %f 至 %f, %.2f%%)", label, asset.Symbol, asset.StrategyID, reasonLabel(reason), asset.EventPrice, latestClose, pnlPercent)
+			stopEvents = append(stopEvents, report)
+
+			record := ClosedPosition{
+				Symbol:     asset.Symbol,
+				StrategyID: asset.StrategyID,
+				Market:     asset.Market,
+				Status:     asset.Status,
+				EventPrice: asset.EventPrice,
+				EventDate:  asset.EventDate,
+				ClosePrice: latestClose,
+				CloseDate:  time.Now().Format("2006-01-02"),
+				Reason:     reason,
+				PnLPercent: pnlPercent,
 			}
-		} else {
-			builder.WriteString("- 无\n")
+			if err := appendHistory(record); err != nil {
+				log.Printf("错误: 写入平仓历史失败: %v", err)
+			}
+			delete(trackedAssets, key)
+			continue
 		}
-		builder.WriteString("\n")
-	}
-
-	formatSection("🚀 当日突破 (MA60)", dailyBreakouts)
-	formatSection("🚨 当日跌破 (MA60)", dailyBreakdowns)
-	formatSection("📈 已突破币种追踪", trackedGains)
-	formatSection("📉 已跌破币种追踪", trackedLosses)
 
-	// ... (发送HTTP请求的代码与之前版本相同)
-	dingTalkMsg := DingTalkMessage{
-		MsgType:  "markdown",
-		Markdown: DingTalkMarkdown{Title: "MA60 均线监控", Text: builder.String()},
-	}
-	jsonData, _ := json.Marshal(dingTalkMsg)
-	req, _ := http.NewRequest("POST", DINGTALK_WEBHOOK_URL, bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("错误: 发送钉钉消息失败: %v", err)
-		return
+		if asset.Status == string(SignalBreakout) && latestClose > asset.EventPrice {
+			gain := (latestClose - asset.EventPrice) / asset.EventPrice * 100
+			report := fmt.Sprintf("%s %s [%s] (从 %f 至今涨幅: %.2f%%)", label, asset.Symbol, asset.StrategyID, asset.EventPrice, gain)
+			trackedGains = append(trackedGains, report)
+		}
+		if asset.Status == string(SignalBreakdown) && latestClose < asset.EventPrice {
+			loss := (asset.EventPrice - latestClose) / asset.EventPrice * 100
+			report := fmt.Sprintf("%s %s [%s] (从 %f 至今跌幅: %.2f%%)", label, asset.Symbol, asset.StrategyID, asset.EventPrice, loss)
+			trackedLosses = append(trackedLosses, report)
+		}
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	log.Printf("钉钉消息发送成功, 响应: %s", string(body))
+	return
 }
 
 // --- 辅助函数 (与之前版本相同) ---
 
-func getAllUSDTSymbols() ([]string, error) {
-	// ...
+// MonitoredSymbol 描述一个被纳入监控范围的交易对及其所属市场
+type MonitoredSymbol struct {
+	Symbol   string
+	Market   MarketMode
+	Contract ContractInfo
+}
+
+func getAllUSDTSymbols() ([]MonitoredSymbol, error) {
 	exchangeInfo, err := binanceClient.NewExchangeInfoService().Do(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	var usdtSymbols []string
+	var usdtSymbols []MonitoredSymbol
 	for _, s := range exchangeInfo.Symbols {
 		if s.QuoteAsset == "USDT" && s.Status == "TRADING" && s.IsSpotTradingAllowed {
-			usdtSymbols = append(usdtSymbols, s.Symbol)
+			usdtSymbols = append(usdtSymbols, MonitoredSymbol{
+				Symbol:   s.Symbol,
+				Market:   MarketModeSpot,
+				Contract: ContractInfo{TickSize: priceFilterTickSize(s), QtyStep: lotSizeStepSize(s)},
+			})
+		}
+	}
+	return usdtSymbols, nil
+}
+
+// getAllFuturesUSDTSymbols 返回当前可交易的 USDT-M 永续/交割合约及其合约元数据
+func getAllFuturesUSDTSymbols() ([]MonitoredSymbol, error) {
+	exchangeInfo, err := futuresClient.NewExchangeInfoService().Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var usdtSymbols []MonitoredSymbol
+	for _, s := range exchangeInfo.Symbols {
+		if s.QuoteAsset != "USDT" || s.Status != "TRADING" {
+			continue
 		}
+		info := ContractInfo{
+			TickSize:      futuresPriceFilterTickSize(s),
+			QtyStep:       futuresLotSizeStepSize(s),
+			ContractValue: 1, // USDT-M 合约以币本位计价，面值固定为 1
+		}
+		if s.ContractType != "PERPETUAL" && s.DeliveryDate > 0 {
+			info.DeliveryDate = time.UnixMilli(s.DeliveryDate).Format("2006-01-02")
+		}
+		usdtSymbols = append(usdtSymbols, MonitoredSymbol{
+			Symbol:   s.Symbol,
+			Market:   MarketModeFutures,
+			Contract: info,
+		})
 	}
 	return usdtSymbols, nil
 }
 
-type DingTalkMessage struct {
-	MsgType  string           `json:"msgtype"`
-	Markdown DingTalkMarkdown `json:"markdown"`
+// priceFilterTickSize 从现货交易对过滤器中提取最小价格变动单位
+func priceFilterTickSize(s binance.Symbol) float64 {
+	if f := s.PriceFilter(); f != nil {
+		v, _ := strconv.ParseFloat(f.TickSize, 64)
+		return v
+	}
+	return 0
+}
+
+// lotSizeStepSize 从现货交易对过滤器中提取最小下单数量步长
+func lotSizeStepSize(s binance.Symbol) float64 {
+	if f := s.LotSizeFilter(); f != nil {
+		v, _ := strconv.ParseFloat(f.StepSize, 64)
+		return v
+	}
+	return 0
+}
+
+// futuresPriceFilterTickSize 从合约交易对过滤器中提取最小价格变动单位
+func futuresPriceFilterTickSize(s futures.Symbol) float64 {
+	if f := s.PriceFilter(); f != nil {
+		v, _ := strconv.ParseFloat(f.TickSize, 64)
+		return v
+	}
+	return 0
 }
-type DingTalkMarkdown struct {
-	Title string `json:"title"`
-	Text  string `json:"text"`
+
+// futuresLotSizeStepSize 从合约交易对过滤器中提取最小下单数量步长
+func futuresLotSizeStepSize(s futures.Symbol) float64 {
+	if f := s.LotSizeFilter(); f != nil {
+		v, _ := strconv.ParseFloat(f.StepSize, 64)
+		return v
+	}
+	return 0
 }
+
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles 内嵌所有按编号命名的迁移脚本（rockhopper 风格：NNNN_name.up.sql，按顺序依次应用）
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// runMigrations 依次应用所有尚未记录在 schema_migrations 表中的迁移
+func runMigrations(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL)`); err != nil {
+		return fmt.Errorf("初始化 schema_migrations 失败: %w", err)
+	}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("读取内嵌迁移目录失败: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		version, name, err := parseMigrationFilename(entry.Name())
+		if err != nil {
+			return err
+		}
+
+		var applied int
+		_ = db.QueryRow(`SELECT COUNT(1) FROM schema_migrations WHERE version = ?`, version).Scan(&applied)
+		if applied > 0 {
+			continue
+		}
+
+		sqlBytes, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return fmt.Errorf("读取迁移文件 %s 失败: %w", entry.Name(), err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("开启迁移事务失败: %w", err)
+		}
+		if _, err := tx.Exec(string(sqlBytes)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("应用迁移 %s 失败: %w", entry.Name(), err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, version, name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("记录迁移 %s 失败: %w", entry.Name(), err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("提交迁移 %s 失败: %w", entry.Name(), err)
+		}
+		log.Printf("已应用数据库迁移: %s", entry.Name())
+	}
+	return nil
+}
+
+// parseMigrationFilename 从 "0001_init.up.sql" 中解析出版本号和名称
+func parseMigrationFilename(filename string) (int, string, error) {
+	base := strings.TrimSuffix(filename, ".up.sql")
+	parts := strings.SplitN(base, "_", 2)
+	if len(parts) != 2 {
+		return 0, "", fmt.Errorf("非法迁移文件名: %s", filename)
+	}
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("迁移文件名缺少数字前缀: %s", filename)
+	}
+	return version, parts[1], nil
+}
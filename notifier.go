@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReportSection 是日报中的一个分段，标题加一组条目
+type ReportSection struct {
+	Title string
+	Items []string
+}
+
+// Report 是一轮检测汇总出的完整日报，既包含渲染好的分段文本，也携带原始信号
+// 供 Email 的阈值触发等需要程序化判断的通知方式使用。
+type Report struct {
+	Title    string
+	Sections []ReportSection
+	Signals  []Signal
+}
+
+// notifierTimeout 是每个通知渠道单次发送允许的最长耗时，超时只影响该渠道
+const notifierTimeout = 10 * time.Second
+
+// Notifier 是所有通知渠道需要实现的统一接口
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, report Report) error
+}
+
+// dispatchNotifications 根据 NOTIFIERS 环境变量并发分发报告，单个渠道失败不影响其余渠道
+func dispatchNotifications(report Report) {
+	notifiers := buildNotifiers(os.Getenv("NOTIFIERS"))
+	if len(notifiers) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, n := range notifiers {
+		wg.Add(1)
+		go func(n Notifier) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), notifierTimeout)
+			defer cancel()
+			if err := n.Send(ctx, report); err != nil {
+				log.Printf("错误: 通知渠道 %s 发送失败: %v", n.Name(), err)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+// buildNotifiers 解析 NOTIFIERS=dingtalk,telegram,email 这样的配置，逐个构造通知渠道；
+// 未设置时默认沿用 DingTalk，保持对早期用户的兼容。
+func buildNotifiers(raw string) []Notifier {
+	names := strings.Split(raw, ",")
+	if strings.TrimSpace(raw) == "" {
+		names = []string{"dingtalk"}
+	}
+
+	var notifiers []Notifier
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "dingtalk":
+			if DINGTALK_WEBHOOK_URL != "" {
+				notifiers = append(notifiers, &DingTalkNotifier{WebhookURL: DINGTALK_WEBHOOK_URL})
+			}
+		case "telegram":
+			if token, chatID := os.Getenv("TELEGRAM_BOT_TOKEN"), os.Getenv("TELEGRAM_CHAT_ID"); token != "" && chatID != "" {
+				notifiers = append(notifiers, &TelegramNotifier{BotToken: token, ChatID: chatID})
+			}
+		case "slack":
+			if url := os.Getenv("SLACK_WEBHOOK_URL"); url != "" {
+				notifiers = append(notifiers, &SlackNotifier{WebhookURL: url})
+			}
+		case "discord":
+			if url := os.Getenv("DISCORD_WEBHOOK_URL"); url != "" {
+				notifiers = append(notifiers, &DiscordNotifier{WebhookURL: url})
+			}
+		case "email":
+			if n := newEmailNotifierFromEnv(); n != nil {
+				notifiers = append(notifiers, n)
+			}
+		case "webhook":
+			if url := os.Getenv("GENERIC_WEBHOOK_URL"); url != "" {
+				notifiers = append(notifiers, &GenericWebhookNotifier{URL: url})
+			}
+		case "":
+			// 忽略空白片段（如 "dingtalk,,telegram"）
+		default:
+			log.Printf("警告: 未知的通知渠道 %q，已忽略", name)
+		}
+	}
+	return notifiers
+}
+
+// renderMarkdown 把报告渲染成各家 Markdown 风格消息平台通用的文本格式
+func renderMarkdown(report Report) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("### %s (%s)\n\n", report.Title, time.Now().Format("2006-01-02")))
+	for _, section := range report.Sections {
+		builder.WriteString(fmt.Sprintf("**%s**\n\n", section.Title))
+		if len(section.Items) > 0 {
+			for _, item := range section.Items {
+				builder.WriteString(fmt.Sprintf("- %s\n", item))
+			}
+		} else {
+			builder.WriteString("- 无\n")
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// postJSON 是各 Webhook 类通知渠道共用的小工具：POST 一段 JSON 并返回响应体
+func postJSON(ctx context.Context, url string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("构造请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// DingTalkNotifier 通过钉钉自定义机器人 Webhook 发送 Markdown 消息
+type DingTalkNotifier struct {
+	WebhookURL string
+}
+
+func (n *DingTalkNotifier) Name() string { return "dingtalk" }
+
+func (n *DingTalkNotifier) Send(ctx context.Context, report Report) error {
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": report.Title,
+			"text":  renderMarkdown(report),
+		},
+	}
+	body, err := postJSON(ctx, n.WebhookURL, payload)
+	if err != nil {
+		return fmt.Errorf("发送钉钉消息失败: %w", err)
+	}
+	log.Printf("钉钉消息发送成功, 响应: %s", string(body))
+	return nil
+}
+
+// TelegramNotifier 通过 Telegram Bot API 的 sendMessage 接口推送消息
+type TelegramNotifier struct {
+	BotToken string
+	ChatID   string
+}
+
+func (n *TelegramNotifier) Name() string { return "telegram" }
+
+func (n *TelegramNotifier) Send(ctx context.Context, report Report) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", n.BotToken)
+	payload := map[string]interface{}{
+		"chat_id":    n.ChatID,
+		"text":       renderMarkdown(report),
+		"parse_mode": "Markdown",
+	}
+	if _, err := postJSON(ctx, url, payload); err != nil {
+		return fmt.Errorf("发送Telegram消息失败: %w", err)
+	}
+	log.Println("Telegram 消息发送成功。")
+	return nil
+}
+
+// SlackNotifier 通过 Slack Incoming Webhook 推送消息
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+func (n *SlackNotifier) Name() string { return "slack" }
+
+func (n *SlackNotifier) Send(ctx context.Context, report Report) error {
+	payload := map[string]string{"text": renderMarkdown(report)}
+	if _, err := postJSON(ctx, n.WebhookURL, payload); err != nil {
+		return fmt.Errorf("发送Slack消息失败: %w", err)
+	}
+	log.Println("Slack 消息发送成功。")
+	return nil
+}
+
+// DiscordNotifier 通过 Discord Webhook 推送消息
+type DiscordNotifier struct {
+	WebhookURL string
+}
+
+func (n *DiscordNotifier) Name() string { return "discord" }
+
+func (n *DiscordNotifier) Send(ctx context.Context, report Report) error {
+	content := renderMarkdown(report)
+	// Discord 单条消息限制 2000 字符，超长时截断并提示
+	if len(content) > 1990 {
+		content = content[:1990] + "\n...(已截断)"
+	}
+	payload := map[string]string{"content": content}
+	if _, err := postJSON(ctx, n.WebhookURL, payload); err != nil {
+		return fmt.Errorf("发送Discord消息失败: %w", err)
+	}
+	log.Println("Discord 消息发送成功。")
+	return nil
+}
+
+// GenericWebhookNotifier 向任意地址 POST 一份模板化的JSON，用于对接未内置支持的平台
+type GenericWebhookNotifier struct {
+	URL string
+}
+
+func (n *GenericWebhookNotifier) Name() string { return "webhook" }
+
+func (n *GenericWebhookNotifier) Send(ctx context.Context, report Report) error {
+	payload := map[string]interface{}{
+		"title":     report.Title,
+		"text":      renderMarkdown(report),
+		"signals":   report.Signals,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	if _, err := postJSON(ctx, n.URL, payload); err != nil {
+		return fmt.Errorf("发送通用Webhook失败: %w", err)
+	}
+	log.Println("通用 Webhook 发送成功。")
+	return nil
+}
+
+// PriceBound 描述一个交易对的邮件告警触发区间，价格突破任一边界才会发信
+type PriceBound struct {
+	High float64
+	Low  float64
+}
+
+// EmailNotifier 通过 SMTP 发送邮件；默认每轮都发送完整日报，若配置了 TriggerPrice
+// 规则，则只在某个交易对的信号价格突破用户设定的高/低阈值时才发信。
+type EmailNotifier struct {
+	SMTPHost string
+	SMTPPort string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Triggers map[string]PriceBound // 为空表示不限制，每轮都发送
+}
+
+func (n *EmailNotifier) Name() string { return "email" }
+
+func (n *EmailNotifier) Send(_ context.Context, report Report) error {
+	if len(n.Triggers) > 0 && !n.anyTriggerCrossed(report.Signals) {
+		return nil
+	}
+
+	subject := report.Title
+	body := renderMarkdown(report)
+	msg := fmt.Sprintf("Subject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s", subject, body)
+
+	auth := smtp.PlainAuth("", n.Username, n.Password, n.SMTPHost)
+	addr := n.SMTPHost + ":" + n.SMTPPort
+	if err := smtp.SendMail(addr, auth, n.From, n.To, []byte(msg)); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	log.Println("邮件发送成功。")
+	return nil
+}
+
+// anyTriggerCrossed 检查本轮信号中是否有交易对触发了用户配置的价格阈值
+func (n *EmailNotifier) anyTriggerCrossed(signals []Signal) bool {
+	for _, sig := range signals {
+		bound, ok := n.Triggers[sig.Symbol]
+		if !ok {
+			continue
+		}
+		if sig.Price >= bound.High || sig.Price <= bound.Low {
+			return true
+		}
+	}
+	return false
+}
+
+// newEmailNotifierFromEnv 从环境变量构造 EmailNotifier，缺少必要配置时返回 nil
+func newEmailNotifierFromEnv() *EmailNotifier {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	from := os.Getenv("SMTP_FROM")
+	to := os.Getenv("SMTP_TO")
+	if host == "" || port == "" || from == "" || to == "" {
+		log.Println("警告: 邮件通知缺少必要的 SMTP_HOST/SMTP_PORT/SMTP_FROM/SMTP_TO 配置，已跳过。")
+		return nil
+	}
+
+	var recipients []string
+	for _, addr := range strings.Split(to, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			recipients = append(recipients, addr)
+		}
+	}
+
+	return &EmailNotifier{
+		SMTPHost: host,
+		SMTPPort: port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+		To:       recipients,
+		Triggers: parseEmailTriggers(os.Getenv("EMAIL_TRIGGER_RULES")),
+	}
+}
+
+// parseEmailTriggers 解析形如 "BTCUSDT:60000:50000,ETHUSDT:4000:2000" 的 高/低 阈值配置
+func parseEmailTriggers(raw string) map[string]PriceBound {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	triggers := make(map[string]PriceBound)
+	for _, rule := range strings.Split(raw, ",") {
+		parts := strings.Split(strings.TrimSpace(rule), ":")
+		if len(parts) != 3 {
+			log.Printf("警告: 忽略非法的 EMAIL_TRIGGER_RULES 片段: %q", rule)
+			continue
+		}
+		high, errHigh := strconv.ParseFloat(parts[1], 64)
+		low, errLow := strconv.ParseFloat(parts[2], 64)
+		if errHigh != nil || errLow != nil {
+			log.Printf("警告: 忽略非法的 EMAIL_TRIGGER_RULES 片段: %q", rule)
+			continue
+		}
+		triggers[strings.ToUpper(parts[0])] = PriceBound{High: high, Low: low}
+	}
+	return triggers
+}
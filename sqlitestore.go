@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStateStore 把追踪状态持久化到 SQLite，避免每轮检测都整文件重写，
+// 并额外记录 signal_history 表，使历史信号可用于回测分析。
+type SQLiteStateStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStateStore 打开（或创建）SQLite 数据库，应用所有待执行的迁移，
+// 并在 tracked_assets 表为空且存在旧版 state.json 时自动导入一次。
+func NewSQLiteStateStore(path string) (*SQLiteStateStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开 SQLite 数据库失败: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接 SQLite 数据库失败: %w", err)
+	}
+	if err := runMigrations(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &SQLiteStateStore{db: db}
+	if err := store.importLegacyJSONIfEmpty(STATE_FILE); err != nil {
+		log.Printf("警告: 导入旧版 %s 失败: %v", STATE_FILE, err)
+	}
+	return store, nil
+}
+
+// importLegacyJSONIfEmpty 在 tracked_assets 表为空时，尝试从旧版 state.json 导入一次性数据
+func (s *SQLiteStateStore) importLegacyJSONIfEmpty(jsonPath string) error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(1) FROM tracked_assets`).Scan(&count); err != nil {
+		return fmt.Errorf("查询 tracked_assets 失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取 %s 失败: %w", jsonPath, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var legacy map[string]TrackedAsset
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", jsonPath, err)
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	migrated := make(map[string]TrackedAsset, len(legacy))
+	for _, asset := range legacy {
+		if asset.Market == "" {
+			asset.Market = string(MarketModeSpot)
+		}
+		if asset.StrategyID == "" {
+			asset.StrategyID = "ma60"
+		}
+		migrated[assetKey(asset.Symbol, asset.StrategyID, asset.Market)] = asset
+	}
+
+	log.Printf("首次运行：从 %s 导入 %d 条历史追踪记录到 SQLite。", jsonPath, len(migrated))
+	return s.Save(migrated)
+}
+
+func (s *SQLiteStateStore) Load() (map[string]TrackedAsset, error) {
+	rows, err := s.db.Query(`SELECT symbol, strategy_id, status, event_price, event_date, market FROM tracked_assets`)
+	if err != nil {
+		return nil, fmt.Errorf("查询 tracked_assets 失败: %w", err)
+	}
+	defer rows.Close()
+
+	assets := make(map[string]TrackedAsset)
+	for rows.Next() {
+		var asset TrackedAsset
+		if err := rows.Scan(&asset.Symbol, &asset.StrategyID, &asset.Status, &asset.EventPrice, &asset.EventDate, &asset.Market); err != nil {
+			return nil, fmt.Errorf("读取 tracked_assets 记录失败: %w", err)
+		}
+		assets[assetKey(asset.Symbol, asset.StrategyID, asset.Market)] = asset
+	}
+	return assets, rows.Err()
+}
+
+// Save 用整批追踪状态覆盖 tracked_assets 表：在同一事务内先按主键 upsert，
+// 再删除不再出现在 assets 中的行，使其行为与 JSONStateStore.Save 的整文件重写
+// 语义一致（否则止损/止盈平仓后从 map 中删除的资产会在 SQLite 里永远残留）。
+func (s *SQLiteStateStore) Save(assets map[string]TrackedAsset) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	stmt, err := tx.Prepare(`
+		INSERT INTO tracked_assets (symbol, strategy_id, status, event_price, event_date, market, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (symbol, strategy_id, market) DO UPDATE SET
+			status = excluded.status,
+			event_price = excluded.event_price,
+			event_date = excluded.event_date,
+			updated_at = excluded.updated_at
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("准备 upsert 语句失败: %w", err)
+	}
+
+	for _, asset := range assets {
+		if _, err := stmt.Exec(asset.Symbol, asset.StrategyID, asset.Status, asset.EventPrice, asset.EventDate, asset.Market, now); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return fmt.Errorf("写入 %s/%s 失败: %w", asset.Symbol, asset.StrategyID, err)
+		}
+	}
+	stmt.Close()
+
+	rows, err := tx.Query(`SELECT symbol, strategy_id, market FROM tracked_assets`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("查询现存 tracked_assets 失败: %w", err)
+	}
+	type rowKey struct{ symbol, strategyID, market string }
+	var stale []rowKey
+	for rows.Next() {
+		var k rowKey
+		if err := rows.Scan(&k.symbol, &k.strategyID, &k.market); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return fmt.Errorf("读取现存 tracked_assets 失败: %w", err)
+		}
+		if _, ok := assets[assetKey(k.symbol, k.strategyID, k.market)]; !ok {
+			stale = append(stale, k)
+		}
+	}
+	rows.Close()
+
+	deleteStmt, err := tx.Prepare(`DELETE FROM tracked_assets WHERE symbol = ? AND strategy_id = ? AND market = ?`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("准备删除语句失败: %w", err)
+	}
+	for _, k := range stale {
+		if _, err := deleteStmt.Exec(k.symbol, k.strategyID, k.market); err != nil {
+			deleteStmt.Close()
+			tx.Rollback()
+			return fmt.Errorf("删除 %s/%s/%s 失败: %w", k.symbol, k.strategyID, k.market, err)
+		}
+	}
+	deleteStmt.Close()
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	log.Printf("成功将 %d 个币种的状态保存到 SQLite，清理 %d 条过期记录。", len(assets), len(stale))
+	return nil
+}
+
+// RecordSignal 把一条信号写入 signal_history 表，供后续回测/复盘查询
+func (s *SQLiteStateStore) RecordSignal(sig Signal, market string, occurredAt string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO signal_history (symbol, strategy_id, market, signal_type, price, message, occurred_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sig.Symbol, sig.StrategyID, market, string(sig.Type), sig.Price, sig.Message, occurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("写入 signal_history 失败: %w", err)
+	}
+	return nil
+}
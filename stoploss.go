@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// HISTORY_FILE 记录所有已平仓（触发止损/止盈）资产的历史
+const HISTORY_FILE = "history.json"
+
+// 默认止损/止盈阈值（百分比），可通过 STOP_LOSS_PCT / TAKE_PROFIT_PCT 环境变量覆盖
+const (
+	defaultStopLossPct   = 10.0
+	defaultTakeProfitPct = 20.0
+)
+
+var (
+	stopLossPct   float64
+	takeProfitPct float64
+)
+
+// initStopLossConfig 从环境变量读取止损/止盈阈值，解析失败或未设置时使用默认值
+func initStopLossConfig() {
+	stopLossPct = envFloatOrDefault("STOP_LOSS_PCT", defaultStopLossPct)
+	takeProfitPct = envFloatOrDefault("TAKE_PROFIT_PCT", defaultTakeProfitPct)
+}
+
+func envFloatOrDefault(key string, def float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("警告: 环境变量 %s 不是合法数字，使用默认值 %.2f", key, def)
+		return def
+	}
+	return v
+}
+
+// ClosedPosition 是一条已平仓记录，写入 history.json 供事后复盘
+type ClosedPosition struct {
+	Symbol     string  `json:"symbol"`
+	StrategyID string  `json:"strategyId"`
+	Market     string  `json:"market"`
+	Status     string  `json:"status"` // 触发前的状态："breakout"/"breakdown"/"buy"/"sell"
+	EventPrice float64 `json:"eventPrice"`
+	EventDate  string  `json:"eventDate"`
+	ClosePrice float64 `json:"closePrice"`
+	CloseDate  string  `json:"closeDate"`
+	Reason     string  `json:"reason"` // "stop_loss" 或 "take_profit"
+	PnLPercent float64 `json:"pnlPercent"`
+}
+
+// checkStopThreshold 判断当前资产是否触发止损或止盈，返回是否平仓、原因及盈亏百分比
+func checkStopThreshold(asset TrackedAsset, latestClose float64) (closed bool, reason string, pnlPercent float64) {
+	switch asset.Status {
+	case string(SignalBreakout), string(SignalBuy):
+		// 多头：价格跌破事件价一定比例止损，涨超一定比例止盈
+		pnlPercent = (latestClose - asset.EventPrice) / asset.EventPrice * 100
+	case string(SignalBreakdown), string(SignalSell):
+		// 空头：价格反弹超过事件价一定比例止损，跌超一定比例止盈
+		pnlPercent = (asset.EventPrice - latestClose) / asset.EventPrice * 100
+	default:
+		return false, "", 0
+	}
+
+	if pnlPercent <= -stopLossPct {
+		return true, "stop_loss", pnlPercent
+	}
+	if pnlPercent >= takeProfitPct {
+		return true, "take_profit", pnlPercent
+	}
+	return false, "", 0
+}
+
+// appendHistory 将一条已平仓记录追加写入 history.json
+func appendHistory(record ClosedPosition) error {
+	var history []ClosedPosition
+	data, err := os.ReadFile(HISTORY_FILE)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("读取历史记录文件失败: %w", err)
+		}
+	} else if len(data) > 0 {
+		if err := json.Unmarshal(data, &history); err != nil {
+			return fmt.Errorf("解析历史记录文件失败: %w", err)
+		}
+	}
+
+	history = append(history, record)
+
+	out, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化历史记录失败: %w", err)
+	}
+	if err := os.WriteFile(HISTORY_FILE, out, 0644); err != nil {
+		return fmt.Errorf("写入历史记录文件失败: %w", err)
+	}
+	return nil
+}
+
+// reasonLabel 把内部使用的英文原因转换为报告中展示的中文描述
+func reasonLabel(reason string) string {
+	if reason == "take_profit" {
+		return "止盈"
+	}
+	return "止损"
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// StateStore 抽象了 trackedAssets 的持久化方式，便于在 JSON 文件和数据库之间切换
+type StateStore interface {
+	// Load 读取全部追踪状态
+	Load() (map[string]TrackedAsset, error)
+	// Save 整体覆盖保存追踪状态
+	Save(assets map[string]TrackedAsset) error
+	// RecordSignal 记录一条信号历史，供回测/复盘使用；JSON 实现可以选择不保留历史
+	RecordSignal(sig Signal, market string, occurredAt string) error
+}
+
+// NewStateStore 根据 STATE_BACKEND 环境变量选择存储后端，默认优先尝试 SQLite，失败时回退到 JSON 文件
+func NewStateStore(backend string) StateStore {
+	switch backend {
+	case "json":
+		return &JSONStateStore{path: STATE_FILE}
+	default:
+		store, err := NewSQLiteStateStore("state.db")
+		if err != nil {
+			log.Printf("警告: 初始化 SQLite 状态存储失败 (%v)，回退到 JSON 文件。", err)
+			return &JSONStateStore{path: STATE_FILE}
+		}
+		return store
+	}
+}
+
+// JSONStateStore 是原始的单文件 JSON 存储方式，作为 SQLite 不可用时的兼容实现
+type JSONStateStore struct {
+	path string
+}
+
+func (s *JSONStateStore) Load() (map[string]TrackedAsset, error) {
+	assets := make(map[string]TrackedAsset)
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Println("状态文件不存在，将创建一个新的。")
+			return assets, nil
+		}
+		return nil, fmt.Errorf("读取状态文件失败: %w", err)
+	}
+	if len(data) == 0 {
+		return assets, nil
+	}
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, fmt.Errorf("解析状态文件JSON失败: %w", err)
+	}
+
+	// 迁移旧版状态：补全缺失的 Market/StrategyID 字段，并将旧的 "symbol" 键迁移为 "symbol@strategyId"
+	migrated := make(map[string]TrackedAsset, len(assets))
+	for _, asset := range assets {
+		if asset.Market == "" {
+			asset.Market = string(MarketModeSpot)
+		}
+		if asset.StrategyID == "" {
+			asset.StrategyID = "ma60"
+		}
+		migrated[assetKey(asset.Symbol, asset.StrategyID, asset.Market)] = asset
+	}
+
+	log.Printf("成功从 %s 加载 %d 个币种的状态。", s.path, len(migrated))
+	return migrated, nil
+}
+
+func (s *JSONStateStore) Save(assets map[string]TrackedAsset) error {
+	data, err := json.MarshalIndent(assets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化状态到JSON失败: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	log.Printf("成功将 %d 个币种的状态保存到 %s。", len(assets), s.path)
+	return nil
+}
+
+// RecordSignal 对 JSON 存储而言是无操作的，历史查询能力由 SQLiteStateStore 提供
+func (s *JSONStateStore) RecordSignal(sig Signal, market string, occurredAt string) error {
+	return nil
+}
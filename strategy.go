@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/adshao/go-binance/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// STRATEGY_CONFIG_FILE 是策略配置文件的默认路径，支持 .yaml/.yml/.json
+const STRATEGY_CONFIG_FILE = "strategies.yaml"
+
+// SignalType 描述一次策略触发的具体含义
+type SignalType string
+
+const (
+	SignalBreakout  SignalType = "breakout"
+	SignalBreakdown SignalType = "breakdown"
+	SignalBuy       SignalType = "buy"
+	SignalSell      SignalType = "sell"
+)
+
+// Signal 代表某个策略在某个交易对上触发的一次信号
+type Signal struct {
+	Symbol     string
+	StrategyID string
+	Market     string // "spot" 或 "futures"；现货和合约可能共用同一个代码，必须随信号一起携带
+	Type       SignalType
+	Price      float64
+	Message    string
+}
+
+// Strategy 是所有指标/策略实现需要满足的接口
+type Strategy interface {
+	// ID 返回策略在配置中对应的唯一标识，同时也是 TrackedAsset.StrategyID 的取值
+	ID() string
+	// Evaluate 根据最新K线和该交易对在本策略下的历史追踪状态，计算出本轮触发的信号
+	Evaluate(klines []*binance.Kline, state *TrackedAsset) []Signal
+}
+
+// StrategyConfig 对应配置文件中的一条策略定义
+type StrategyConfig struct {
+	Name     string                 `json:"name" yaml:"name"`
+	Interval string                 `json:"interval" yaml:"interval"`
+	Params   map[string]interface{} `json:"params" yaml:"params"`
+	Symbols  []string               `json:"symbols" yaml:"symbols"`
+}
+
+// LoadStrategyConfigs 从 YAML 或 JSON 文件加载策略列表；文件不存在时返回默认的 MA60 策略以保持向后兼容
+func LoadStrategyConfigs(path string) ([]StrategyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("策略配置文件 %s 不存在，使用默认 MA60 策略。", path)
+			return []StrategyConfig{{Name: "ma60", Interval: "1d"}}, nil
+		}
+		return nil, fmt.Errorf("读取策略配置文件失败: %w", err)
+	}
+
+	var configs []StrategyConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &configs)
+	default:
+		err = yaml.Unmarshal(data, &configs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析策略配置文件失败: %w", err)
+	}
+	return configs, nil
+}
+
+// BuildStrategy 根据配置构造对应的 Strategy 实现
+func BuildStrategy(cfg StrategyConfig) (Strategy, error) {
+	switch strings.ToLower(cfg.Name) {
+	case "ma60":
+		return &MA60Strategy{id: strategyID(cfg)}, nil
+	case "sma_cross", "ema_cross":
+		fast := intParam(cfg.Params, "fast", 12)
+		slow := intParam(cfg.Params, "slow", 26)
+		return &CrossoverStrategy{id: strategyID(cfg), fast: fast, slow: slow, useEMA: strings.EqualFold(cfg.Name, "ema_cross")}, nil
+	case "bollinger_squeeze":
+		return &BollingerSqueezeStrategy{
+			id:     strategyID(cfg),
+			period: intParam(cfg.Params, "period", 20),
+			numStd: floatParam(cfg.Params, "stdDev", 2),
+		}, nil
+	case "rsi_divergence":
+		return &RSIDivergenceStrategy{
+			id:         strategyID(cfg),
+			period:     intParam(cfg.Params, "period", 14),
+			lookback:   intParam(cfg.Params, "lookback", 14),
+			overbought: floatParam(cfg.Params, "overbought", 70),
+			oversold:   floatParam(cfg.Params, "oversold", 30),
+		}, nil
+	case "macd_cross":
+		return &MACDCrossStrategy{
+			id:     strategyID(cfg),
+			fast:   intParam(cfg.Params, "fast", 12),
+			slow:   intParam(cfg.Params, "slow", 26),
+			signal: intParam(cfg.Params, "signal", 9),
+		}, nil
+	case "circular":
+		return &CircularStrategy{
+			id:          strategyID(cfg),
+			basePrice:   floatParam(cfg.Params, "basePrice", 0),
+			fluctuation: floatParam(cfg.Params, "fluctuation", 0.02),
+		}, nil
+	default:
+		return nil, fmt.Errorf("未知策略类型: %s", cfg.Name)
+	}
+}
+
+// strategyID 生成策略实例的唯一标识，优先使用配置中的 name，便于在 state.json 中区分
+func strategyID(cfg StrategyConfig) string {
+	return strings.ToLower(cfg.Name)
+}
+
+func intParam(params map[string]interface{}, key string, def int) int {
+	if v, ok := params[key]; ok {
+		switch t := v.(type) {
+		case int:
+			return t
+		case float64:
+			return int(t)
+		case string:
+			if n, err := strconv.Atoi(t); err == nil {
+				return n
+			}
+		}
+	}
+	return def
+}
+
+func floatParam(params map[string]interface{}, key string, def float64) float64 {
+	if v, ok := params[key]; ok {
+		switch t := v.(type) {
+		case float64:
+			return t
+		case int:
+			return float64(t)
+		case string:
+			if f, err := strconv.ParseFloat(t, 64); err == nil {
+				return f
+			}
+		}
+	}
+	return def
+}
+
+// closePrices 从K线中提取收盘价序列
+func closePrices(klines []*binance.Kline) []float64 {
+	prices := make([]float64, len(klines))
+	for i, k := range klines {
+		p, _ := strconv.ParseFloat(k.Close, 64)
+		prices[i] = p
+	}
+	return prices
+}
+
+// sma 计算窗口为 period 的简单移动平均，要求 len(prices) >= period
+func sma(prices []float64, period int) float64 {
+	var sum float64
+	for _, p := range prices[len(prices)-period:] {
+		sum += p
+	}
+	return sum / float64(period)
+}
+
+// ema 计算收盘价序列末尾的指数移动平均
+func ema(prices []float64, period int) float64 {
+	k := 2.0 / float64(period+1)
+	result := prices[0]
+	for _, p := range prices[1:] {
+		result = p*k + result*(1-k)
+	}
+	return result
+}
+
+// MA60Strategy 是最初版本的 60 日均线突破/跌破策略
+type MA60Strategy struct {
+	id string
+}
+
+func (s *MA60Strategy) ID() string { return s.id }
+
+func (s *MA60Strategy) Evaluate(klines []*binance.Kline, state *TrackedAsset) []Signal {
+	if len(klines) < 61 {
+		return nil
+	}
+	prices := closePrices(klines)
+	ma60 := sma(prices[len(prices)-61:len(prices)-1], 60)
+	previousClose := prices[len(prices)-2]
+	latestClose := prices[len(prices)-1]
+
+	if latestClose > ma60 && previousClose <= ma60 {
+		return []Signal{{Type: SignalBreakout, Price: latestClose, Message: fmt.Sprintf("突破MA60 (价格: %f)", latestClose)}}
+	}
+	if latestClose < ma60 && previousClose >= ma60 {
+		return []Signal{{Type: SignalBreakdown, Price: latestClose, Message: fmt.Sprintf("跌破MA60 (价格: %f)", latestClose)}}
+	}
+	return nil
+}
+
+// CrossoverStrategy 实现可配置快/慢均线的 SMA 或 EMA 金叉死叉策略
+type CrossoverStrategy struct {
+	id     string
+	fast   int
+	slow   int
+	useEMA bool
+}
+
+func (s *CrossoverStrategy) ID() string { return s.id }
+
+func (s *CrossoverStrategy) Evaluate(klines []*binance.Kline, state *TrackedAsset) []Signal {
+	if len(klines) < s.slow+1 {
+		return nil
+	}
+	prices := closePrices(klines)
+	avg := sma
+	if s.useEMA {
+		avg = ema
+	}
+
+	// sma 只看传入切片的尾部 period 个点，窗口化没有影响；但 ema 需要从头递推，
+	// 必须喂入完整的历史前缀，否则每次都从子窗口起点重新播种，算出来的根本不是EMA
+	// （参考 MACDCrossStrategy 的 macdAt 同样喂入完整前缀的做法）。
+	prevFast := avg(prices[:len(prices)-1], s.fast)
+	prevSlow := avg(prices[:len(prices)-1], s.slow)
+	curFast := avg(prices, s.fast)
+	curSlow := avg(prices, s.slow)
+	latestClose := prices[len(prices)-1]
+
+	if curFast > curSlow && prevFast <= prevSlow {
+		return []Signal{{Type: SignalBuy, Price: latestClose, Message: fmt.Sprintf("快线上穿慢线 (fast=%d, slow=%d)", s.fast, s.slow)}}
+	}
+	if curFast < curSlow && prevFast >= prevSlow {
+		return []Signal{{Type: SignalSell, Price: latestClose, Message: fmt.Sprintf("快线下穿慢线 (fast=%d, slow=%d)", s.fast, s.slow)}}
+	}
+	return nil
+}
+
+// BollingerSqueezeStrategy 在布林带收窄后重新放大时给出信号
+type BollingerSqueezeStrategy struct {
+	id     string
+	period int
+	numStd float64
+}
+
+func (s *BollingerSqueezeStrategy) ID() string { return s.id }
+
+// bollingerSqueezeLookback 是判断"挤压前是否足够窄"所参照的基线宽度窗口数
+const bollingerSqueezeLookback = 10
+
+// bollingerSqueezeRatio 是挤压阈值：上一轮带宽低于基线宽度的这个比例才算真正的挤压
+const bollingerSqueezeRatio = 0.8
+
+// bollingerExpansionRatio 是突破阈值：本轮带宽相对上一轮放大超过这个倍数视为挤压后的放量突破
+const bollingerExpansionRatio = 1.5
+
+// bollingerBandWidth 计算 window 末尾 period 个收盘价对应的布林带宽度 (2 * numStd * 标准差)
+func bollingerBandWidth(window []float64, period int, numStd float64) float64 {
+	mean := sma(window, period)
+	var variance float64
+	for _, p := range window[len(window)-period:] {
+		variance += (p - mean) * (p - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(period))
+	return 2 * numStd * stdDev
+}
+
+func (s *BollingerSqueezeStrategy) Evaluate(klines []*binance.Kline, state *TrackedAsset) []Signal {
+	if len(klines) < s.period+bollingerSqueezeLookback+1 {
+		return nil
+	}
+	prices := closePrices(klines)
+
+	// widths[i] 是以 prices 末尾往前数第 (lookback-i) 根K线为截止点的带宽，widths 末位是当前带宽
+	widths := make([]float64, bollingerSqueezeLookback+1)
+	for i := range widths {
+		end := len(prices) - bollingerSqueezeLookback + i
+		widths[i] = bollingerBandWidth(prices[:end], s.period, s.numStd)
+	}
+	curWidth := widths[bollingerSqueezeLookback]
+	prevWidth := widths[bollingerSqueezeLookback-1]
+	baselineWidth := sma(widths[:bollingerSqueezeLookback], bollingerSqueezeLookback)
+	latestClose := prices[len(prices)-1]
+
+	// 只有上一轮带宽明显窄于基线(真正处于挤压状态)，且本轮重新放大，才算挤压后突破
+	wasSqueezed := baselineWidth > 0 && prevWidth < baselineWidth*bollingerSqueezeRatio
+	if wasSqueezed && prevWidth > 0 && curWidth > prevWidth*bollingerExpansionRatio {
+		return []Signal{{Type: SignalBuy, Price: latestClose, Message: "布林带挤压后放量突破"}}
+	}
+	return nil
+}
+
+// RSIDivergenceStrategy 比较价格与 RSI 在回溯窗口内的摆动高/低点方向是否背离：
+// 价格创出新高而 RSI 未能同步创新高(顶背离)，或价格创出新低而 RSI 未能同步创新低(底背离)，
+// 分别提示上涨/下跌动能衰竭。
+type RSIDivergenceStrategy struct {
+	id         string
+	period     int // RSI 计算窗口
+	lookback   int // 在多少根K线内寻找价格的前一个摆动高/低点用于比较
+	overbought float64
+	oversold   float64
+}
+
+func (s *RSIDivergenceStrategy) ID() string { return s.id }
+
+// rsiAt 计算 prices[idx-period+1 ..= idx] 窗口内的简单RSI
+func rsiAt(prices []float64, idx, period int) float64 {
+	var gainSum, lossSum float64
+	for i := idx - period + 1; i <= idx; i++ {
+		diff := prices[i] - prices[i-1]
+		if diff > 0 {
+			gainSum += diff
+		} else {
+			lossSum -= diff
+		}
+	}
+	if lossSum == 0 {
+		return 100
+	}
+	rs := (gainSum / float64(period)) / (lossSum / float64(period))
+	return 100 - (100 / (1 + rs))
+}
+
+func (s *RSIDivergenceStrategy) Evaluate(klines []*binance.Kline, state *TrackedAsset) []Signal {
+	needed := s.period + s.lookback + 1
+	if len(klines) < needed {
+		return nil
+	}
+	prices := closePrices(klines)
+	latestIdx := len(prices) - 1
+	latestClose := prices[latestIdx]
+	latestRSI := rsiAt(prices, latestIdx, s.period)
+
+	// 在回溯窗口内找到价格的前一个摆动高点/低点(局部极值)，与最新K线比较方向是否背离
+	windowStart := latestIdx - s.lookback
+	prevHighIdx, prevLowIdx := windowStart, windowStart
+	for i := windowStart; i < latestIdx; i++ {
+		if prices[i] > prices[prevHighIdx] {
+			prevHighIdx = i
+		}
+		if prices[i] < prices[prevLowIdx] {
+			prevLowIdx = i
+		}
+	}
+
+	if latestClose > prices[prevHighIdx] && latestRSI < rsiAt(prices, prevHighIdx, s.period) && latestRSI >= s.overbought {
+		if state != nil && state.Status == string(SignalSell) {
+			return nil // 已处于卖出状态，价格维持高位期间不重复触发
+		}
+		return []Signal{{Type: SignalSell, Price: latestClose, Message: fmt.Sprintf("顶背离: 价格新高但RSI走弱 (%.2f)", latestRSI)}}
+	}
+	if latestClose < prices[prevLowIdx] && latestRSI > rsiAt(prices, prevLowIdx, s.period) && latestRSI <= s.oversold {
+		if state != nil && state.Status == string(SignalBuy) {
+			return nil // 已处于买入状态，价格维持低位期间不重复触发
+		}
+		return []Signal{{Type: SignalBuy, Price: latestClose, Message: fmt.Sprintf("底背离: 价格新低但RSI走强 (%.2f)", latestRSI)}}
+	}
+	return nil
+}
+
+// MACDCrossStrategy 在 MACD 与信号线交叉时给出信号
+type MACDCrossStrategy struct {
+	id     string
+	fast   int
+	slow   int
+	signal int
+}
+
+func (s *MACDCrossStrategy) ID() string { return s.id }
+
+func (s *MACDCrossStrategy) Evaluate(klines []*binance.Kline, state *TrackedAsset) []Signal {
+	needed := s.slow + s.signal + 1
+	if len(klines) < needed {
+		return nil
+	}
+	prices := closePrices(klines)
+
+	macdAt := func(upto []float64) float64 {
+		return ema(upto, s.fast) - ema(upto, s.slow)
+	}
+
+	var macdSeries []float64
+	for i := s.slow; i <= len(prices); i++ {
+		macdSeries = append(macdSeries, macdAt(prices[:i]))
+	}
+	if len(macdSeries) < s.signal+2 {
+		return nil
+	}
+
+	prevMACD := macdSeries[len(macdSeries)-2]
+	curMACD := macdSeries[len(macdSeries)-1]
+	prevSignal := ema(macdSeries[:len(macdSeries)-1], s.signal)
+	curSignal := ema(macdSeries, s.signal)
+	latestClose := prices[len(prices)-1]
+
+	if curMACD > curSignal && prevMACD <= prevSignal {
+		return []Signal{{Type: SignalBuy, Price: latestClose, Message: "MACD 金叉"}}
+	}
+	if curMACD < curSignal && prevMACD >= prevSignal {
+		return []Signal{{Type: SignalSell, Price: latestClose, Message: "MACD 死叉"}}
+	}
+	return nil
+}
+
+// CircularStrategy 围绕一个基准价格来回触发买卖信号，适合震荡行情的网格式追踪
+type CircularStrategy struct {
+	id          string
+	basePrice   float64
+	fluctuation float64
+}
+
+func (s *CircularStrategy) ID() string { return s.id }
+
+func (s *CircularStrategy) Evaluate(klines []*binance.Kline, state *TrackedAsset) []Signal {
+	if len(klines) == 0 || s.basePrice <= 0 {
+		return nil
+	}
+	latestClose, _ := strconv.ParseFloat(klines[len(klines)-1].Close, 64)
+
+	upper := s.basePrice * (1 + s.fluctuation)
+	lower := s.basePrice * (1 - s.fluctuation)
+
+	if latestClose >= upper {
+		if state != nil && state.Status == string(SignalSell) {
+			return nil // 已处于卖出状态，价格维持在上轨之外期间不重复触发
+		}
+		return []Signal{{Type: SignalSell, Price: latestClose, Message: fmt.Sprintf("高于基准价 %.2f%%，触发卖出", s.fluctuation*100)}}
+	}
+	if latestClose <= lower {
+		if state != nil && state.Status == string(SignalBuy) {
+			return nil // 已处于买入状态，价格维持在下轨之外期间不重复触发
+		}
+		return []Signal{{Type: SignalBuy, Price: latestClose, Message: fmt.Sprintf("低于基准价 %.2f%%，触发买入", s.fluctuation*100)}}
+	}
+	return nil
+}
+
+// runStrategiesConcurrently 为每个配置的策略在各自的交易对范围内并发执行 Evaluate。
+// 直接遍历 universe（而不是先按裸 symbol 建一张 map）是为了避免现货和合约共用同一个代码
+// 时互相覆盖——同一个 symbol 在 universe 中可能同时有 spot 和 futures 两条记录。
+func runStrategiesConcurrently(configs []StrategyConfig, universe []MonitoredSymbol) []Signal {
+	var mu sync.Mutex
+	var allSignals []Signal
+	var wg sync.WaitGroup
+
+	for _, cfg := range configs {
+		strat, err := BuildStrategy(cfg)
+		if err != nil {
+			log.Printf("错误: 构建策略 %s 失败: %v", cfg.Name, err)
+			continue
+		}
+
+		var allowedSymbols map[string]bool
+		if len(cfg.Symbols) > 0 {
+			allowedSymbols = make(map[string]bool, len(cfg.Symbols))
+			for _, symbol := range cfg.Symbols {
+				allowedSymbols[symbol] = true
+			}
+		}
+
+		for _, ms := range universe {
+			if allowedSymbols != nil && !allowedSymbols[ms.Symbol] {
+				continue
+			}
+			wg.Add(1)
+			go func(strat Strategy, ms MonitoredSymbol, interval string) {
+				defer wg.Done()
+				if interval == "" {
+					interval = "1d"
+				}
+				klines, err := fetchKlines(ms, interval, 200)
+				if err != nil || len(klines) == 0 {
+					return
+				}
+				key := assetKey(ms.Symbol, strat.ID(), string(ms.Market))
+				prior := trackedAssets[key]
+				signals := strat.Evaluate(klines, &prior)
+				if len(signals) == 0 {
+					return
+				}
+				for i := range signals {
+					signals[i].Symbol = ms.Symbol
+					signals[i].StrategyID = strat.ID()
+					signals[i].Market = string(ms.Market)
+				}
+				mu.Lock()
+				allSignals = append(allSignals, signals...)
+				mu.Unlock()
+			}(strat, ms, cfg.Interval)
+		}
+	}
+
+	wg.Wait()
+	return allSignals
+}
+
+// assetKey 组合市场、交易对和策略ID，作为 state.json/SQLite 中每条追踪记录的唯一键。
+// 现货和合约可能使用完全相同的代码（如 BTCUSDT 在两边都存在），不带市场的键会让两边互相覆盖。
+func assetKey(symbol, strategyID, market string) string {
+	return market + ":" + symbol + "@" + strategyID
+}
+
+// fetchKlines 根据市场类型选择对应的客户端拉取K线
+func fetchKlines(ms MonitoredSymbol, interval string, limit int) ([]*binance.Kline, error) {
+	if ms.Market == MarketModeFutures {
+		futuresKlines, err := futuresClient.NewKlinesService().Symbol(ms.Symbol).Interval(interval).Limit(limit).Do(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		converted := make([]*binance.Kline, len(futuresKlines))
+		for i, k := range futuresKlines {
+			converted[i] = &binance.Kline{
+				Open:  k.Open,
+				High:  k.High,
+				Low:   k.Low,
+				Close: k.Close,
+			}
+		}
+		return converted, nil
+	}
+	return binanceClient.NewKlinesService().Symbol(ms.Symbol).Interval(interval).Limit(limit).Do(context.Background())
+}
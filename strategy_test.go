@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/adshao/go-binance/v2"
+)
+
+func TestSMA(t *testing.T) {
+	prices := []float64{1, 2, 3, 4, 5}
+	if got := sma(prices, 5); got != 3 {
+		t.Errorf("sma(5) = %v, want 3", got)
+	}
+	if got := sma(prices, 2); got != 4.5 {
+		t.Errorf("sma(2) = %v, want 4.5", got)
+	}
+}
+
+func TestEMASeededFromSliceStart(t *testing.T) {
+	// ema() 总是从传入切片的第一个元素开始递推，因此喂入不同起点的切片会得到不同结果——
+	// 这正是 CrossoverStrategy 过去只喂尾部窗口而出错的原因，这里锁定该行为不回归。
+	full := []float64{10, 11, 12, 20, 21, 22}
+	fromStart := ema(full, 3)
+	fromMiddle := ema(full[3:], 3)
+	if fromStart == fromMiddle {
+		t.Errorf("ema() 喂入不同起点的切片应得到不同结果，但都等于 %v", fromStart)
+	}
+}
+
+func TestCrossoverStrategyEMAUsesFullPrefix(t *testing.T) {
+	// 回归测试：fast/slow 均线必须基于同一份完整历史前缀递推，而不是各自独立的尾部窗口
+	prices := []float64{
+		100, 101, 99, 102, 98, 103, 97, 104, 96, 105,
+		94, 106, 93, 107, 92, 115, 120, 125,
+	}
+	klines := pricesToKlines(prices)
+	s := &CrossoverStrategy{id: "ema_cross", fast: 3, slow: 6, useEMA: true}
+
+	all := closePrices(klines)
+	wantPrevFast := ema(all[:len(all)-1], 3)
+	wantPrevSlow := ema(all[:len(all)-1], 6)
+	wantCurFast := ema(all, 3)
+	wantCurSlow := ema(all, 6)
+	wantSignal := wantCurFast > wantCurSlow && wantPrevFast <= wantPrevSlow
+
+	signals := s.Evaluate(klines, &TrackedAsset{})
+	gotSignal := len(signals) == 1 && signals[0].Type == SignalBuy
+	if gotSignal != wantSignal {
+		t.Errorf("CrossoverStrategy.Evaluate() buy-signal = %v, want %v (curFast=%.4f curSlow=%.4f prevFast=%.4f prevSlow=%.4f)",
+			gotSignal, wantSignal, wantCurFast, wantCurSlow, wantPrevFast, wantPrevSlow)
+	}
+}
+
+func TestRSIAt(t *testing.T) {
+	// 连续上涨：RSI 应为 100（lossSum == 0）
+	upOnly := []float64{10, 11, 12, 13, 14}
+	if got := rsiAt(upOnly, len(upOnly)-1, len(upOnly)-1); got != 100 {
+		t.Errorf("rsiAt(连续上涨) = %v, want 100", got)
+	}
+
+	// 涨跌各半，RSI 应落在 (0, 100) 区间内
+	mixed := []float64{10, 12, 11, 13, 10, 14}
+	got := rsiAt(mixed, len(mixed)-1, len(mixed)-1)
+	if got <= 0 || got >= 100 {
+		t.Errorf("rsiAt(涨跌混合) = %v, want in (0, 100)", got)
+	}
+}
+
+func TestBollingerBandWidthWidensWithVolatility(t *testing.T) {
+	flat := make([]float64, 20)
+	for i := range flat {
+		flat[i] = 100
+	}
+	volatile := []float64{100, 105, 95, 108, 92, 110, 90, 112, 88, 114, 86, 116, 84, 118, 82, 120, 80, 122, 78, 124}
+
+	flatWidth := bollingerBandWidth(flat, 20, 2)
+	volatileWidth := bollingerBandWidth(volatile, 20, 2)
+	if flatWidth != 0 {
+		t.Errorf("bollingerBandWidth(无波动) = %v, want 0", flatWidth)
+	}
+	if volatileWidth <= flatWidth {
+		t.Errorf("bollingerBandWidth(高波动) = %v, 应大于无波动时的 %v", volatileWidth, flatWidth)
+	}
+}
+
+func TestCircularStrategySuppressesRepeatSignal(t *testing.T) {
+	s := &CircularStrategy{id: "circular", basePrice: 100, fluctuation: 0.02}
+	klines := pricesToKlines([]float64{103})
+
+	first := s.Evaluate(klines, &TrackedAsset{})
+	if len(first) != 1 || first[0].Type != SignalSell {
+		t.Fatalf("首次触发应返回1个卖出信号，got %+v", first)
+	}
+
+	// 价格维持在上轨之外、且已处于 sell 状态时，不应重复触发
+	repeat := s.Evaluate(klines, &TrackedAsset{Status: string(SignalSell)})
+	if len(repeat) != 0 {
+		t.Errorf("价格维持高位且已处于卖出状态时不应重复触发，got %+v", repeat)
+	}
+}
+
+// pricesToKlines 把一组收盘价包装成 Evaluate 所需的K线切片，其余字段测试中用不到
+func pricesToKlines(prices []float64) []*binance.Kline {
+	klines := make([]*binance.Kline, len(prices))
+	for i, p := range prices {
+		klines[i] = &binance.Kline{Close: strconv.FormatFloat(p, 'f', -1, 64)}
+	}
+	return klines
+}
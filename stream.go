@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// WatchMode 决定 schedule() 使用每日轮询还是实时K线推送
+type WatchMode string
+
+const (
+	WatchModePoll   WatchMode = "poll"
+	WatchModeStream WatchMode = "stream"
+)
+
+// oneDayMillis 是 1d K线两次收盘之间的预期间隔，用于在重连后判断是否出现缺口
+const oneDayMillis = 24 * 60 * 60 * 1000
+
+// parseWatchMode 读取并校验 WATCH_MODE 环境变量，默认值为 poll，不影响现有用户
+func parseWatchMode(raw string) WatchMode {
+	if strings.EqualFold(strings.TrimSpace(raw), string(WatchModeStream)) {
+		return WatchModeStream
+	}
+	return WatchModePoll
+}
+
+var (
+	streamStateMu      sync.Mutex
+	lastCandleOpenTime = map[string]int64{} // 键为 universeKey(market, symbol)，避免现货/合约同代码冲突
+
+	streamUniverseMu sync.RWMutex
+	streamUniverse   map[string]MonitoredSymbol // 本次重连周期缓存的监控范围，键同上；避免每个事件都调用REST
+)
+
+// setStreamUniverse 缓存当前重连周期的监控范围，供后续每次K线事件直接查表，
+// 不必像每日轮询那样可以承受一次 REST 调用 —— 流模式下收盘事件是瞬时批量到达的。
+func setStreamUniverse(universe []MonitoredSymbol) {
+	bySymbol := make(map[string]MonitoredSymbol, len(universe))
+	for _, ms := range universe {
+		bySymbol[universeKey(ms.Market, ms.Symbol)] = ms
+	}
+	streamUniverseMu.Lock()
+	streamUniverse = bySymbol
+	streamUniverseMu.Unlock()
+}
+
+// lookupStreamSymbol 从缓存的监控范围中查找给定市场下的交易对
+func lookupStreamSymbol(market MarketMode, symbol string) (MonitoredSymbol, bool) {
+	streamUniverseMu.RLock()
+	defer streamUniverseMu.RUnlock()
+	ms, ok := streamUniverse[universeKey(market, symbol)]
+	return ms, ok
+}
+
+// nextBackoff 把重连退避时间翻倍，不超过 max
+func nextBackoff(cur, max time.Duration) time.Duration {
+	cur *= 2
+	if cur > max {
+		cur = max
+	}
+	return cur
+}
+
+// waitForAny 阻塞直到任意一路K线流断开；现货和合约最多两路，用 select 即可，无需反射
+func waitForAny(doneCs []chan struct{}) {
+	switch len(doneCs) {
+	case 0:
+		return
+	case 1:
+		<-doneCs[0]
+	default:
+		select {
+		case <-doneCs[0]:
+		case <-doneCs[1]:
+		}
+	}
+}
+
+// runStreamMode 订阅所有监控交易对的 1d K线推送（现货和合约各一路订阅），收盘后立即
+// 重放检测逻辑并告警；断线后按指数退避重连，并在重连成功时用 REST 补齐可能错过的K线。
+func runStreamMode() {
+	backoff := time.Second
+	const maxBackoff = 60 * time.Second
+
+	for {
+		universe := buildUniverse()
+		if len(universe) == 0 {
+			log.Println("错误: 流模式没有可订阅的交易对，5秒后重试")
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		setStreamUniverse(universe)
+
+		spotPairs := make(map[string]string)
+		futuresPairs := make(map[string]string)
+		for _, ms := range universe {
+			if ms.Market == MarketModeFutures {
+				futuresPairs[ms.Symbol] = "1d"
+			} else {
+				spotPairs[ms.Symbol] = "1d"
+			}
+		}
+
+		var doneCs []chan struct{}
+		subscribeFailed := false
+
+		if len(spotPairs) > 0 {
+			doneC, _, err := binance.WsCombinedKlineServe(spotPairs, handleSpotStreamKline, handleStreamErr)
+			if err != nil {
+				log.Printf("错误: 建立现货WebSocket订阅失败: %v，%s 后重试", err, backoff)
+				subscribeFailed = true
+			} else {
+				doneCs = append(doneCs, doneC)
+			}
+		}
+		if !subscribeFailed && len(futuresPairs) > 0 {
+			doneC, _, err := futures.WsCombinedKlineServe(futuresPairs, handleFuturesStreamKline, handleStreamErr)
+			if err != nil {
+				log.Printf("错误: 建立合约WebSocket订阅失败: %v，%s 后重试", err, backoff)
+				subscribeFailed = true
+			} else {
+				doneCs = append(doneCs, doneC)
+			}
+		}
+
+		if subscribeFailed {
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff, maxBackoff)
+			continue
+		}
+
+		log.Printf("已订阅 %d 个现货 / %d 个合约交易对的 1d K线流", len(spotPairs), len(futuresPairs))
+		backoff = time.Second
+		waitForAny(doneCs)
+
+		log.Println("WebSocket 连接已断开，通过 REST 重新同步后准备重连...")
+		resyncAllSymbols()
+	}
+}
+
+// handleStreamErr 记录推送异常；连接本身的重连由 runStreamMode 的外层循环负责
+func handleStreamErr(err error) {
+	log.Printf("错误: WebSocket 推送异常: %v", err)
+}
+
+// handleSpotStreamKline 处理现货K线推送
+func handleSpotStreamKline(event *binance.WsKlineEvent) {
+	handleStreamKline(MarketModeSpot, event.Symbol, event.Kline.IsFinal, event.Kline.StartTime)
+}
+
+// handleFuturesStreamKline 处理合约K线推送
+func handleFuturesStreamKline(event *futures.WsKlineEvent) {
+	handleStreamKline(MarketModeFutures, event.Symbol, event.Kline.IsFinal, event.Kline.StartTime)
+}
+
+// handleStreamKline 处理一条K线推送，只在蜡烛收盘(IsFinal)时触发检测
+func handleStreamKline(market MarketMode, symbol string, isFinal bool, startTime int64) {
+	if !isFinal {
+		return
+	}
+
+	key := universeKey(market, symbol)
+	streamStateMu.Lock()
+	prevOpenTime, seen := lastCandleOpenTime[key]
+	lastCandleOpenTime[key] = startTime
+	streamStateMu.Unlock()
+
+	if seen && startTime-prevOpenTime > oneDayMillis*2 {
+		log.Printf("警告: %s(%s) 的K线流检测到缺口 (上次 %d, 本次 %d)，将通过 REST 重新同步", symbol, market, prevOpenTime, startTime)
+		resyncSymbol(symbol, market)
+	}
+
+	processStreamEvent(symbol, market)
+}
+
+// resyncAllSymbols 在重连后对所有监控交易对做一次 REST 全量检测，弥补期间可能漏掉的信号
+func resyncAllSymbols() {
+	assets, err := stateStore.Load()
+	if err != nil {
+		log.Printf("错误: 重连后加载状态失败: %v", err)
+		return
+	}
+	trackedAssets = assets
+
+	dailyBreakouts, dailyBreakdowns, trackedGains, trackedLosses, stopEvents, signals := trackAndAnalyze()
+	dispatchNotifications(Report{
+		Title: "MA60 均线监控",
+		Sections: []ReportSection{
+			{Title: "🔄 重连补齐: 突破", Items: dailyBreakouts},
+			{Title: "🔄 重连补齐: 跌破", Items: dailyBreakdowns},
+			{Title: "📈 已突破币种追踪", Items: trackedGains},
+			{Title: "📉 已跌破币种追踪", Items: trackedLosses},
+			{Title: "🛑 止损触发", Items: stopEvents},
+		},
+		Signals: signals,
+	})
+
+	if err := stateStore.Save(trackedAssets); err != nil {
+		log.Printf("错误: 重连后保存状态失败: %v", err)
+	}
+}
+
+// resyncSymbol 对单个出现缺口的交易对重新拉取最新K线，复用和轮询模式一致的检测逻辑
+func resyncSymbol(symbol string, market MarketMode) {
+	processStreamEvent(symbol, market)
+}
+
+// processStreamEvent 对单个交易对重放所有已配置策略，命中信号后立即告警并持久化。
+// 交易对元数据从本轮重连周期缓存的监控范围中查表获得，不再每次都调用 REST ExchangeInfo——
+// 数百个交易对的日线几乎在同一时刻收盘，逐事件请求会重新制造流模式本应避免的限频风暴。
+func processStreamEvent(symbol string, market MarketMode) {
+	target, found := lookupStreamSymbol(market, symbol)
+	if !found {
+		return
+	}
+
+	configs, err := LoadStrategyConfigs(STRATEGY_CONFIG_FILE)
+	if err != nil {
+		log.Printf("错误: 加载策略配置失败: %v", err)
+		return
+	}
+
+	signals := runStrategiesConcurrently(configs, []MonitoredSymbol{target})
+	if len(signals) == 0 {
+		return
+	}
+
+	label := marketLabel(target.Market)
+	var items []string
+	for _, sig := range signals {
+		key := assetKey(sig.Symbol, sig.StrategyID, sig.Market)
+		items = append(items, fmt.Sprintf("%s %s [%s] %s", label, sig.Symbol, sig.StrategyID, sig.Message))
+
+		if err := stateStore.RecordSignal(sig, string(target.Market), time.Now().Format(time.RFC3339)); err != nil {
+			log.Printf("错误: 记录信号历史失败: %v", err)
+		}
+
+		trackedAssets[key] = TrackedAsset{
+			Symbol:     sig.Symbol,
+			StrategyID: sig.StrategyID,
+			Status:     string(sig.Type),
+			EventPrice: sig.Price,
+			EventDate:  time.Now().Format("2006-01-02"),
+			Market:     string(target.Market),
+			Contract:   target.Contract,
+		}
+	}
+
+	dispatchNotifications(Report{
+		Title:    "MA60 均线监控",
+		Sections: []ReportSection{{Title: fmt.Sprintf("⚡ 实时信号 (%s)", symbol), Items: items}},
+		Signals:  signals,
+	})
+
+	if err := stateStore.Save(trackedAssets); err != nil {
+		log.Printf("错误: 实时保存状态失败: %v", err)
+	}
+}